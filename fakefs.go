@@ -23,33 +23,101 @@
 // to use the File helper. If you'd like to write your own mock or stub or
 // even use a real file you can.
 //
-// The fake filesystem is not even slightly safe for concurrent use. By design
-// concurrent calls to Open() with the same path will return the same file
-// value (allowing you to test equality in your tests if needed). As a
-// result the values will also share the same underlying io.ReadSeeker.
-// Concurrent calls to file.Read() will give unpredictable results.
+// By default the fake filesystem is not safe for concurrent use: concurrent
+// calls to Open() with the same path return the same file value (allowing
+// you to test equality in your tests if needed), so they share the same
+// read position and concurrent calls to file.Read() will give unpredictable
+// results. Pass WithIndependentHandles() to FileSystem if you need Open to
+// hand out independent handles instead, for example to serve the same file
+// to many concurrent requests under httptest.Server.
+//
+// Call AsFS to get an io/fs.FS view of the same tree, for use with the
+// io/fs helpers (fs.ReadDir, fs.ReadFile, fs.Glob, fs.WalkDir) or with
+// testing/fstest.TestFS.
 package fakehttpfs
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
+	"path"
 	"strings"
+	"sync"
 	"time"
 )
 
-// Creates a test fake filesystem containing the files.
-func FileSystem(files ...http.File) http.FileSystem {
-	return &dir{"", files, 0}
+// Option configures the filesystem returned by FileSystem. See
+// WithStrictPaths.
+type Option interface {
+	apply(*fsOptions)
+}
+
+type fsOptions struct {
+	strictPaths        bool
+	independentHandles bool
+	maxSymlinkDepth    int
+}
+
+type optionFunc func(*fsOptions)
+
+func (f optionFunc) apply(o *fsOptions) { f(o) }
+
+// WithStrictPaths restores the package's original, stricter path
+// handling: no "." or ".." cleaning, no trailing slash support, and no
+// automatic index.html serving. It exists for callers who depended on
+// that behavior before FileSystem started cleaning paths like
+// net/http.ServeFile does.
+func WithStrictPaths(strict bool) Option {
+	return optionFunc(func(o *fsOptions) { o.strictPaths = strict })
+}
+
+// WithIndependentHandles makes Open return a fresh http.File handle on
+// every call, wrapping the same shared, immutable content, instead of
+// the package's default of returning the same value (and so the same
+// read position) every time. This makes the filesystem safe for
+// concurrent use by, for example, many requests in a httptest.Server
+// reading the same path at once. Only nodes created by File and Dir get
+// fresh handles; any other http.File in the tree (such as a FaultyFile or
+// a real file) is still returned as the same value every time. The cost
+// is that Open results can no longer be compared with == to check they
+// came from the same node; use Stat().Name() instead.
+func WithIndependentHandles() Option {
+	return optionFunc(func(o *fsOptions) { o.independentHandles = true })
+}
+
+// WithMaxSymlinkDepth overrides the default limit of 40 symlinks followed
+// while resolving a single Open call, after which Open returns
+// ErrSymlinkLoop. See Symlink.
+func WithMaxSymlinkDepth(depth int) Option {
+	return optionFunc(func(o *fsOptions) { o.maxSymlinkDepth = depth })
+}
+
+// Creates a test fake filesystem containing the files. items may also
+// include Options such as WithStrictPaths and WithIndependentHandles.
+func FileSystem(items ...interface{}) http.FileSystem {
+	var opts fsOptions
+	var files []http.File
+	for _, item := range items {
+		switch v := item.(type) {
+		case Option:
+			v.apply(&opts)
+		case http.File:
+			files = append(files, v)
+		default:
+			panic(fmt.Sprintf("Unknown option type %T", item))
+		}
+	}
+	root := &dir{name: "", files: files, strict: opts.strictPaths, independentHandles: opts.independentHandles, maxSymlinkDepth: opts.maxSymlinkDepth}
+	root.linkParents()
+	return root
 }
 
 //  a test fake file with string contents.
 func File(name, contents string, options ...interface{}) http.File {
-	b := []byte(contents)
-	f := file{name: name, size: int64(len(b)), Reader: bytes.NewReader(b)}
+	f := &file{name: name, mode: 0644, content: []byte(contents)}
 
 	for _, o := range options {
 		switch t := o.(type) {
@@ -62,85 +130,289 @@ func File(name, contents string, options ...interface{}) http.File {
 	return f
 }
 
+// file is a fake regular file. Reads, writes and seeks share a single
+// position, guarded by mu, matching the behaviour of a real os.File
+// handle opened once and used from a single goroutine at a time.
 type file struct {
+	mu      sync.Mutex
 	name    string
-	size    int64
+	mode    os.FileMode
 	modTime time.Time
-	*bytes.Reader
+	content []byte
+	pos     int64
 }
 
-func (f file) Stat() (os.FileInfo, error) {
+func (f *file) Stat() (os.FileInfo, error) {
 	return f, nil
 }
 
-func (f file) Readdir(int) ([]os.FileInfo, error) {
+func (f *file) Readdir(int) ([]os.FileInfo, error) {
 	return nil, errors.New("Not dir")
 }
 
-func (f file) Read(b []byte) (int, error) {
-	return f.Reader.Read(b)
+func (f *file) Read(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.pos >= int64(len(f.content)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.content[f.pos:])
+	f.pos += int64(n)
+	return n, nil
 }
 
-func (f file) Seek(offset int64, whence int) (int64, error) {
-	return f.Reader.Seek(offset, whence)
+func (f *file) Write(b []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	end := f.pos + int64(len(b))
+	if end > int64(len(f.content)) {
+		grown := make([]byte, end)
+		copy(grown, f.content)
+		f.content = grown
+	}
+	n := copy(f.content[f.pos:end], b)
+	f.pos += int64(n)
+	return n, nil
 }
 
-func (f file) Close() error {
-	_, err := f.Seek(0, 0)
-	if err != nil {
-		panic(err)
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	pos := f.pos
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos += offset
+	case io.SeekEnd:
+		pos = int64(len(f.content)) + offset
+	default:
+		return 0, errors.New("fakehttpfs: invalid whence")
+	}
+	if pos < 0 {
+		return 0, errors.New("fakehttpfs: negative position")
 	}
+	f.pos = pos
+	return pos, nil
+}
+
+func (f *file) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pos = 0
 	return nil
 }
 
-func (f file) Name() string {
+func (f *file) Name() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	return f.name
 }
 
-func (f file) Size() int64 {
-	return int64(f.Reader.Len())
+func (f *file) Size() int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return int64(len(f.content))
 }
 
-func (f file) Mode() os.FileMode {
-	return 0644
+func (f *file) Mode() os.FileMode {
+	return f.mode
 }
 
-func (f file) ModTime() time.Time {
+func (f *file) ModTime() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	return f.modTime
 }
 
-func (f file) IsDir() bool {
+func (f *file) IsDir() bool {
 	return false
 }
 
-func (f file) Sys() interface{} {
+func (f *file) Sys() interface{} {
 	return nil
 }
 
 // Creates a test fake directory containing the files.
 func Dir(name string, files ...http.File) http.File {
-	return &dir{name, files, 0}
+	return &dir{name: name, files: files}
 }
 
+// dir is a fake directory. mu guards files and position so that handlers
+// under test can list, create and remove entries concurrently.
 type dir struct {
-	name     string
-	files    []http.File
-	position int
+	mu                 sync.Mutex
+	name               string
+	mode               os.FileMode
+	modTime            time.Time
+	files              []http.File
+	position           int
+	strict             bool
+	independentHandles bool
+	maxSymlinkDepth    int
+	parent             *dir
+}
+
+// linkParents sets the parent pointer of every directory in the tree
+// rooted at d, so that Symlink targets containing ".." can be resolved
+// relative to the symlink's containing directory.
+func (d *dir) linkParents() {
+	for _, f := range d.files {
+		if sub, ok := f.(*dir); ok {
+			sub.parent = d
+			sub.linkParents()
+		}
+	}
+}
+
+// root returns the root directory of the tree containing d.
+func (d *dir) root() *dir {
+	cur := d
+	for cur.parent != nil {
+		cur = cur.parent
+	}
+	return cur
 }
 
 func (d *dir) Open(name string) (http.File, error) {
+	var result http.File
+	var err error
+
+	if d.strict {
+		result, err = d.openStrict(name)
+	} else {
+		var parts []string
+		parts, err = cleanPathParts(name)
+		if err == nil {
+			maxDepth := d.maxSymlinkDepth
+			if maxDepth == 0 {
+				maxDepth = defaultMaxSymlinkDepth
+			}
+			depth := 0
+			result, err = d.resolveWithDepth(parts, &depth, maxDepth)
+			if err == nil {
+				if sub, ok := result.(*dir); ok {
+					if index, ierr := sub.find("index.html"); ierr == nil {
+						if link, ok := index.(*symlink); ok {
+							index, ierr = resolveSymlinkTarget(sub, link.target, &depth, maxDepth)
+						}
+						if ierr == nil {
+							result = index
+						}
+					}
+				}
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if d.independentHandles {
+		result = wrapHandle(result)
+	}
+	return result, nil
+}
+
+// wrapHandle returns a fresh http.File over the same shared, immutable
+// content as f, for use by WithIndependentHandles. Types it doesn't
+// recognize (such as a caller-supplied http.File passed to FileSystem)
+// are returned unchanged, since there's no generic way to copy them.
+func wrapHandle(f http.File) http.File {
+	switch t := f.(type) {
+	case *file:
+		t.mu.Lock()
+		content, modTime := t.content, t.modTime
+		t.mu.Unlock()
+		return &file{name: t.name, mode: t.mode, modTime: modTime, content: content}
+	case *dir:
+		t.mu.Lock()
+		modTime := t.modTime
+		t.mu.Unlock()
+		return &dir{
+			name:               t.name,
+			mode:               t.mode,
+			modTime:            modTime,
+			files:              t.files,
+			strict:             t.strict,
+			independentHandles: t.independentHandles,
+			maxSymlinkDepth:    t.maxSymlinkDepth,
+			parent:             t.parent,
+		}
+	default:
+		return f
+	}
+}
+
+// resolveWithDepth walks parts down from d, following each path element
+// in turn, transparently following any Symlink nodes it encounters along
+// the way. depth and maxDepth are shared with the caller so that a
+// symlinked index.html looked up afterwards counts against the same
+// budget as the path that led to it.
+func (d *dir) resolveWithDepth(parts []string, depth *int, maxDepth int) (http.File, error) {
+	var cur http.File = d
+	for _, part := range parts {
+		sub, ok := cur.(*dir)
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		found, err := sub.find(part)
+		if err != nil {
+			return nil, err
+		}
+		if link, ok := found.(*symlink); ok {
+			found, err = resolveSymlinkTarget(sub, link.target, depth, maxDepth)
+			if err != nil {
+				return nil, err
+			}
+		}
+		cur = found
+	}
+	return cur, nil
+}
+
+// cleanPathParts cleans name with path.Clean and splits the result into
+// path elements, treating "." or "/" as the root. path.Clean resolves any
+// ".." element that stays within name, but leaves a leading ".." alone
+// when it would escape above name itself; since that always means
+// escaping the fake root too, such paths are rejected with fs.ErrInvalid.
+func cleanPathParts(name string) ([]string, error) {
+	cleaned := strings.TrimPrefix(path.Clean(name), "/")
+	if cleaned == "" || cleaned == "." {
+		return nil, nil
+	}
+	parts := strings.Split(cleaned, "/")
+	for _, part := range parts {
+		if part == ".." {
+			return nil, fs.ErrInvalid
+		}
+	}
+	return parts, nil
+}
+
+// openStrict is the package's original Open behavior: no path cleaning,
+// no trailing slash support, and no automatic index.html. It's used when
+// FileSystem is created with WithStrictPaths(true).
+func (d *dir) openStrict(name string) (http.File, error) {
 	parts := strings.SplitN(name, "/", 2)
 	file, err := d.find(parts[0])
 	if len(parts) == 1 {
 		return file, err
 	}
 	if subDir, ok := file.(*dir); ok {
-		return subDir.Open(parts[1])
+		return subDir.openStrict(parts[1])
 	}
 	return nil, os.ErrNotExist
 }
 
 func (d *dir) find(name string) (http.File, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.findLocked(name)
+}
+
+// findLocked is find without acquiring d.mu, for callers that already hold it.
+func (d *dir) findLocked(name string) (http.File, error) {
 	if name == "" || name == "." {
 		return d, nil
 	}
@@ -161,6 +433,8 @@ func (d *dir) Stat() (os.FileInfo, error) {
 }
 
 func (d *dir) Readdir(count int) (r []os.FileInfo, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	if count == 0 {
 		r = make([]os.FileInfo, len(d.files))
 		for i, f := range d.files {
@@ -194,11 +468,15 @@ func (d *dir) Seek(offset int64, whence int) (int64, error) {
 }
 
 func (d *dir) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	d.position = 0
 	return nil
 }
 
 func (d *dir) Name() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 	return d.name
 }
 
@@ -207,11 +485,16 @@ func (d *dir) Size() int64 {
 }
 
 func (d *dir) Mode() os.FileMode {
-	return 0755 | os.ModeDir
+	if d.mode == 0 {
+		return 0755 | os.ModeDir
+	}
+	return d.mode
 }
 
 func (d *dir) ModTime() time.Time {
-	panic("unimplemented")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.modTime
 }
 
 func (d *dir) IsDir() bool {
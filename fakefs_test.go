@@ -117,11 +117,8 @@ func TestErrors(t *testing.T) {
 		"foo/baz/baz/oops",
 		"foo/baz/baz/baz/baz/oops",
 		"hello/oops",
-		// we don't do .. cleaning
+		// ".." is cleaned against the fake root, so this still escapes it
 		"../hello",
-		// we don't support trailing slashes
-		"hello/",
-		"/hello/",
 	}
 
 	for _, path := range errTests {
@@ -135,6 +132,83 @@ func TestErrors(t *testing.T) {
 	}
 }
 
+func TestCleanedPaths(t *testing.T) {
+	cleanTests := []struct {
+		path     string
+		contents string
+	}{
+		{"/hello/", "hello"},
+		{"foo/./bar", "BAR"},
+	}
+
+	for _, test := range cleanTests {
+		file, err := testFS.Open(test.path)
+		if err != nil {
+			t.Errorf("expected %s to not error, got %v", test.path, err)
+			continue
+		}
+		b := new(bytes.Buffer)
+		b.ReadFrom(file)
+		file.Close()
+		if s := b.String(); s != test.contents {
+			t.Errorf("expected %s to contain %q, got %q", test.path, test.contents, s)
+		}
+	}
+
+	dir, err := testFS.Open("foo/baz/../baz/baz/baz")
+	if err != nil {
+		t.Fatalf("expected foo/baz/../baz/baz/baz to not error, got %v", err)
+	}
+	stat, err := dir.Stat()
+	if err != nil {
+		t.Fatalf("expected stat to not error, got %v", err)
+	}
+	if !stat.IsDir() {
+		t.Errorf("expected foo/baz/../baz/baz/baz to be a directory, got %v", dir)
+	}
+}
+
+func TestWithStrictPaths(t *testing.T) {
+	strictFS := FileSystem(
+		WithStrictPaths(true),
+		File("hello", "hello"),
+	)
+
+	if _, err := strictFS.Open("/hello/"); err == nil {
+		t.Errorf("expected strict filesystem to reject trailing slashes")
+	}
+	if file, err := strictFS.Open("hello"); err != nil {
+		t.Errorf("expected hello to not error, got %v", err)
+	} else {
+		b := new(bytes.Buffer)
+		b.ReadFrom(file)
+		file.Close()
+		if s := b.String(); s != "hello" {
+			t.Errorf("expected hello to contain %q, got %q", "hello", s)
+		}
+	}
+}
+
+func TestIndexHTML(t *testing.T) {
+	indexFS := FileSystem(
+		Dir("misc",
+			File("index.html", "<html>misc</html>"),
+			File("other.txt", "other"),
+		),
+	)
+
+	file, err := indexFS.Open("misc")
+	if err != nil {
+		t.Fatalf("expected misc to not error, got %v", err)
+	}
+	b := new(bytes.Buffer)
+	b.ReadFrom(file)
+	file.Close()
+	if s := b.String(); s != "<html>misc</html>" {
+		t.Errorf("expected misc to serve index.html, got %q", s)
+	}
+}
+
 func TestOtherFiles(t *testing.T) {
 	tmpFile, err := ioutil.TempFile("", "fakehttpfs")
 	name := path.Base(tmpFile.Name())
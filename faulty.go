@@ -0,0 +1,114 @@
+// Copyright 2014 Paul Hammond.
+// This software is licensed under the MIT license, see LICENSE.txt for details.
+
+package fakehttpfs
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Faults configures error and latency injection for FaultyFile, for
+// exercising the range-request and conditional-GET paths in
+// net/http.ServeContent and http.FileServer. The zero value of each
+// field disables the corresponding fault.
+type Faults struct {
+	// Size, if non-nil, is what Stat().Size() reports, even though it
+	// disagrees with the actual length of contents. Use this to test
+	// handling of a bad Content-Length.
+	Size *int64
+
+	// ReadLimit, if positive, makes Read return io.ErrUnexpectedEOF once
+	// ReadLimit bytes have been read from the file in total.
+	ReadLimit int
+
+	// SeekOffset, SeekWhence and SeekErr make Seek fail with SeekErr
+	// when called with the given offset and whence. SeekErr must be set
+	// for this fault to be enabled, since offset 0 with io.SeekStart is
+	// itself a realistic Seek call made by http.ServeContent.
+	SeekOffset int64
+	SeekWhence int
+	SeekErr    error
+
+	// StatErr, if set, makes Stat fail with this error.
+	StatErr error
+
+	// ReadDelay, if positive, is slept before every Read call returns,
+	// to simulate a slow backend.
+	ReadDelay time.Duration
+}
+
+// FaultyFile creates a test fake file like File, but one that can be made
+// to misbehave in the ways described by faults. The underlying file and
+// its Read/Write/Seek plumbing are reused unchanged; only Read, Seek and
+// Stat are overridden.
+func FaultyFile(name, contents string, faults Faults) http.File {
+	return &faultyFile{
+		file:   &file{name: name, mode: 0644, content: []byte(contents)},
+		faults: faults,
+	}
+}
+
+type faultyFile struct {
+	*file
+	faults    Faults
+	mu        sync.Mutex
+	totalRead int
+}
+
+func (f *faultyFile) Read(b []byte) (int, error) {
+	if f.faults.ReadDelay > 0 {
+		time.Sleep(f.faults.ReadDelay)
+	}
+	if f.faults.ReadLimit > 0 {
+		f.mu.Lock()
+		remaining := f.faults.ReadLimit - f.totalRead
+		f.mu.Unlock()
+		if remaining <= 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if len(b) > remaining {
+			b = b[:remaining]
+		}
+	}
+	n, err := f.file.Read(b)
+	if f.faults.ReadLimit > 0 {
+		f.mu.Lock()
+		f.totalRead += n
+		f.mu.Unlock()
+	}
+	return n, err
+}
+
+func (f *faultyFile) Seek(offset int64, whence int) (int64, error) {
+	if f.faults.SeekErr != nil && offset == f.faults.SeekOffset && whence == f.faults.SeekWhence {
+		return 0, f.faults.SeekErr
+	}
+	return f.file.Seek(offset, whence)
+}
+
+func (f *faultyFile) Stat() (os.FileInfo, error) {
+	if f.faults.StatErr != nil {
+		return nil, f.faults.StatErr
+	}
+	info, err := f.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if f.faults.Size != nil {
+		return sizeOverride{FileInfo: info, size: *f.faults.Size}, nil
+	}
+	return info, nil
+}
+
+// sizeOverride wraps an os.FileInfo, reporting size from Size() instead of
+// deferring to the wrapped value.
+type sizeOverride struct {
+	os.FileInfo
+	size int64
+}
+
+func (s sizeOverride) Size() int64 { return s.size }
@@ -0,0 +1,74 @@
+// Copyright 2014 Paul Hammond.
+// This software is licensed under the MIT license, see LICENSE.txt for details.
+
+package fakehttpfs
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestFaultyFileSize(t *testing.T) {
+	size := int64(100)
+	f := FaultyFile("hello", "hello", Faults{Size: &size})
+
+	stat, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if stat.Size() != 100 {
+		t.Errorf("expected Size() to be 100, got %d", stat.Size())
+	}
+}
+
+func TestFaultyFileReadLimit(t *testing.T) {
+	f := FaultyFile("hello", "hello world", Faults{ReadLimit: 5})
+
+	b := make([]byte, 5)
+	n, err := f.Read(b)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if n != 5 {
+		t.Fatalf("expected to read 5 bytes, got %d", n)
+	}
+
+	if _, err := f.Read(b); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func TestFaultyFileSeekErr(t *testing.T) {
+	wantErr := errors.New("seek boom")
+	f := FaultyFile("hello", "hello", Faults{SeekOffset: 2, SeekWhence: io.SeekStart, SeekErr: wantErr})
+
+	if _, err := f.Seek(2, io.SeekStart); err != wantErr {
+		t.Errorf("expected %v, got %v", wantErr, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Errorf("expected other seeks to be unaffected, got %v", err)
+	}
+}
+
+func TestFaultyFileStatErr(t *testing.T) {
+	wantErr := errors.New("stat boom")
+	f := FaultyFile("hello", "hello", Faults{StatErr: wantErr})
+
+	if _, err := f.Stat(); err != wantErr {
+		t.Errorf("expected %v, got %v", wantErr, err)
+	}
+}
+
+func TestFaultyFileUnfaulted(t *testing.T) {
+	f := FaultyFile("hello", "hello", Faults{})
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(b) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", string(b))
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2014 Paul Hammond.
+// This software is licensed under the MIT license, see LICENSE.txt for details.
+
+package fakehttpfs
+
+import (
+	"io"
+	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+)
+
+// AsFS adapts an http.FileSystem (as returned by FileSystem) to the
+// io/fs.FS interface, along with fs.ReadDirFS, fs.StatFS, fs.ReadFileFS,
+// and fs.GlobFS. This allows the same in-memory tree used to test
+// net/http handlers to be used with io/fs helpers such as fs.ReadDir,
+// fs.ReadFile, fs.Glob, fs.WalkDir, and testing/fstest.TestFS.
+//
+// Names passed to the returned fs.FS follow fs.ValidPath rules: no
+// leading slash, no ".." elements, and "." for the root.
+func AsFS(hfs http.FileSystem) fs.FS {
+	return fsAdapter{hfs}
+}
+
+type fsAdapter struct {
+	hfs http.FileSystem
+}
+
+func (a fsAdapter) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := a.hfs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		entries, err := readEntries(f)
+		if err != nil {
+			return nil, err
+		}
+		return &fsDir{File: f, entries: entries}, nil
+	}
+	return f, nil
+}
+
+// fsDir adapts an http.File directory to fs.ReadDirFile, which requires a
+// ReadDir method distinct from http.File's Readdir. Unlike the underlying
+// *dir, whose Readdir position is shared by every caller, fsDir keeps its
+// own read position so concurrent and repeated fs.FS reads of the same
+// directory behave independently, as io/fs requires.
+type fsDir struct {
+	http.File
+	entries  []fs.DirEntry
+	position int
+}
+
+func readEntries(f http.File) ([]fs.DirEntry, error) {
+	infos, err := f.Readdir(0)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (d *fsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries[d.position:]
+		d.position = len(d.entries)
+		return entries, nil
+	}
+	remaining := len(d.entries) - d.position
+	if n > remaining {
+		n = remaining
+	}
+	entries := d.entries[d.position : d.position+n]
+	d.position += n
+	if len(entries) == 0 {
+		return nil, io.EOF
+	}
+	return entries, nil
+}
+
+func (a fsAdapter) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := a.hfs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	infos, err := f.Readdir(0)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (a fsAdapter) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := a.hfs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Stat()
+}
+
+func (a fsAdapter) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := a.hfs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// ReadLink implements fs.ReadLinkFS (Go 1.25), returning the raw target
+// of a symlink created by Symlink, without following it.
+func (a fsAdapter) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return Readlink(a.hfs, name)
+}
+
+// Lstat implements fs.ReadLinkFS (Go 1.25), returning info about name
+// without following a symlink in its final path element.
+func (a fsAdapter) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	root, err := rootDirOf(a.hfs)
+	if err != nil {
+		return nil, err
+	}
+	node, err := lookupRaw(root, name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: err}
+	}
+	return node.Stat()
+}
+
+func (a fsAdapter) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	var matches []string
+	err := fs.WalkDir(a, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ok, _ := path.Match(pattern, p); ok {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
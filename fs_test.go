@@ -0,0 +1,25 @@
+// Copyright 2014 Paul Hammond.
+// This software is licensed under the MIT license, see LICENSE.txt for details.
+
+package fakehttpfs
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestAsFSCompliance(t *testing.T) {
+	hfs := FileSystem(
+		Dir("foo",
+			File("bar", "BAR"),
+			Dir("baz",
+				File("qux", "QUX"),
+			),
+		),
+		File("hello", "hello"),
+	)
+
+	if err := fstest.TestFS(AsFS(hfs), "foo/bar", "foo/baz/qux", "hello"); err != nil {
+		t.Fatal(err)
+	}
+}
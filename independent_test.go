@@ -0,0 +1,137 @@
+// Copyright 2014 Paul Hammond.
+// This software is licensed under the MIT license, see LICENSE.txt for details.
+
+package fakehttpfs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestWithIndependentHandles(t *testing.T) {
+	independentFS := FileSystem(
+		WithIndependentHandles(),
+		File("hello", "hello world"),
+	)
+
+	const goroutines = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f, err := independentFS.Open("hello")
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer f.Close()
+			b, err := ioutil.ReadAll(f)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if string(b) != "hello world" {
+				errs <- fmt.Errorf("expected %q, got %q", "hello world", string(b))
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+}
+
+func TestWithIndependentHandlesSeparatePositions(t *testing.T) {
+	independentFS := FileSystem(
+		WithIndependentHandles(),
+		File("hello", "hello world"),
+	)
+
+	a, err := independentFS.Open("hello")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b, err := independentFS.Open("hello")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	buf := make([]byte, 5)
+	if _, err := a.Read(buf); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(buf) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", string(buf))
+	}
+
+	full, err := ioutil.ReadAll(b)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(full) != "hello world" {
+		t.Errorf("expected second handle to start from its own position, got %q", string(full))
+	}
+}
+
+func TestWithIndependentHandlesSymlinkThroughWrappedDir(t *testing.T) {
+	independentFS := FileSystem(
+		WithIndependentHandles(),
+		File("real.txt", "hello"),
+		Dir("sub", Symlink("link.txt", "../real.txt")),
+	)
+
+	subHandle, err := independentFS.Open("sub")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	sub, ok := subHandle.(http.FileSystem)
+	if !ok {
+		t.Fatalf("expected wrapped subdirectory handle to still implement http.FileSystem")
+	}
+
+	file, err := sub.Open("link.txt")
+	if err != nil {
+		t.Fatalf("unexpected error resolving \"..\" symlink through wrapped subdirectory: %v", err)
+	}
+	defer file.Close()
+	b, err := ioutil.ReadAll(file)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(b) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", string(b))
+	}
+}
+
+func TestWrapHandlePreservesDirOptions(t *testing.T) {
+	parent := &dir{name: ""}
+	original := &dir{
+		name:               "sub",
+		parent:             parent,
+		independentHandles: true,
+		maxSymlinkDepth:    7,
+	}
+
+	clone, ok := wrapHandle(original).(*dir)
+	if !ok {
+		t.Fatalf("expected wrapHandle to return a *dir, got %T", wrapHandle(original))
+	}
+	if clone.parent != parent {
+		t.Errorf("expected wrapHandle to preserve parent, got %v", clone.parent)
+	}
+	if !clone.independentHandles {
+		t.Errorf("expected wrapHandle to preserve independentHandles")
+	}
+	if clone.maxSymlinkDepth != 7 {
+		t.Errorf("expected wrapHandle to preserve maxSymlinkDepth, got %d", clone.maxSymlinkDepth)
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2014 Paul Hammond.
+// This software is licensed under the MIT license, see LICENSE.txt for details.
+
+package fakehttpfs
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMaxSymlinkDepth is the default value of WithMaxSymlinkDepth,
+// matching Linux's own symlink resolution limit.
+const defaultMaxSymlinkDepth = 40
+
+// ErrSymlinkLoop is returned by Open and Readlink when resolving a
+// symlink would follow more than the configured maximum number of
+// symlinks, whether because of a genuine loop or simply a long chain.
+var ErrSymlinkLoop = errors.New("fakehttpfs: too many levels of symbolic links")
+
+// Symlink creates a fake symbolic link node pointing at target. target is
+// resolved relative to the symlink's parent directory, or relative to the
+// filesystem root if it begins with "/", the same way a real symlink on
+// disk would be. Symlinks are followed transparently by Open in the
+// package's default, lenient mode (see WithStrictPaths); the raw target
+// can be recovered with Readlink.
+func Symlink(name, target string) http.File {
+	return &symlink{name: name, target: target}
+}
+
+// symlink is a fake symbolic link. Open resolves it to the file or
+// directory it points to; it is never itself returned by Open, except
+// when WithStrictPaths(true) is in effect, which does not follow links.
+type symlink struct {
+	mu     sync.Mutex
+	name   string
+	target string
+}
+
+func (s *symlink) Stat() (os.FileInfo, error) {
+	return s, nil
+}
+
+func (s *symlink) Readdir(int) ([]os.FileInfo, error) {
+	return nil, errors.New("Not dir")
+}
+
+func (s *symlink) Read([]byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: s.name, Err: errors.New("symlink was not resolved")}
+}
+
+func (s *symlink) Seek(int64, int) (int64, error) {
+	return 0, &os.PathError{Op: "seek", Path: s.name, Err: errors.New("symlink was not resolved")}
+}
+
+func (s *symlink) Close() error {
+	return nil
+}
+
+func (s *symlink) Name() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.name
+}
+
+func (s *symlink) Size() int64 {
+	return int64(len(s.target))
+}
+
+func (s *symlink) Mode() os.FileMode {
+	return os.ModeSymlink | 0777
+}
+
+func (s *symlink) ModTime() time.Time {
+	return time.Time{}
+}
+
+func (s *symlink) IsDir() bool {
+	return false
+}
+
+func (s *symlink) Sys() interface{} {
+	return nil
+}
+
+// resolveSymlinkTarget follows the symlink target found in parent,
+// returning the file or directory it ultimately points to. depth counts
+// the total number of symlinks followed so far during the surrounding
+// Open call, so that both loops and long chains are bounded by maxDepth
+// regardless of which symlink in the chain introduces them.
+func resolveSymlinkTarget(parent *dir, target string, depth *int, maxDepth int) (http.File, error) {
+	*depth++
+	if *depth > maxDepth {
+		return nil, ErrSymlinkLoop
+	}
+
+	start := parent
+	cleaned := strings.TrimPrefix(path.Clean(target), "/")
+	if strings.HasPrefix(target, "/") {
+		start = parent.root()
+	}
+
+	var cur http.File = start
+	if cleaned == "" || cleaned == "." {
+		return cur, nil
+	}
+	for _, part := range strings.Split(cleaned, "/") {
+		if part == ".." {
+			sub, ok := cur.(*dir)
+			if !ok || sub.parent == nil {
+				return nil, os.ErrInvalid
+			}
+			cur = sub.parent
+			continue
+		}
+		sub, ok := cur.(*dir)
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		found, err := sub.find(part)
+		if err != nil {
+			return nil, err
+		}
+		if link, ok := found.(*symlink); ok {
+			found, err = resolveSymlinkTarget(sub, link.target, depth, maxDepth)
+			if err != nil {
+				return nil, err
+			}
+		}
+		cur = found
+	}
+	return cur, nil
+}
+
+// lookupRaw resolves all but the last path element of name, following
+// any symlinks along the way exactly as Open does, then returns the raw
+// final node without following it even if it is itself a symlink. It
+// backs both Readlink and the io/fs adapter's Lstat.
+func lookupRaw(root *dir, name string) (http.File, error) {
+	parts, err := cleanPathParts(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return root, nil
+	}
+
+	maxDepth := root.maxSymlinkDepth
+	if maxDepth == 0 {
+		maxDepth = defaultMaxSymlinkDepth
+	}
+	depth := 0
+
+	var cur http.File = root
+	for _, part := range parts[:len(parts)-1] {
+		sub, ok := cur.(*dir)
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		found, err := sub.find(part)
+		if err != nil {
+			return nil, err
+		}
+		if link, ok := found.(*symlink); ok {
+			found, err = resolveSymlinkTarget(sub, link.target, &depth, maxDepth)
+			if err != nil {
+				return nil, err
+			}
+		}
+		cur = found
+	}
+
+	sub, ok := cur.(*dir)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return sub.find(parts[len(parts)-1])
+}
+
+// Readlink returns the raw target of the symlink named name, as created
+// by Symlink, without following it. hfs must be a value returned by
+// FileSystem or Writable.
+func Readlink(hfs http.FileSystem, name string) (string, error) {
+	root, err := rootDirOf(hfs)
+	if err != nil {
+		return "", err
+	}
+	node, err := lookupRaw(root, name)
+	if err != nil {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	link, ok := node.(*symlink)
+	if !ok {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: os.ErrInvalid}
+	}
+	return link.target, nil
+}
+
+// rootDirOf returns the underlying *dir of a filesystem created by
+// FileSystem or Writable, for use by Readlink and the io/fs adapter.
+func rootDirOf(hfs http.FileSystem) (*dir, error) {
+	switch t := hfs.(type) {
+	case *dir:
+		return t, nil
+	case *WritableFileSystem:
+		return t.root, nil
+	default:
+		return nil, errors.New("fakehttpfs: Readlink requires a filesystem created by FileSystem or Writable")
+	}
+}
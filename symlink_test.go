@@ -0,0 +1,208 @@
+// Copyright 2014 Paul Hammond.
+// This software is licensed under the MIT license, see LICENSE.txt for details.
+
+package fakehttpfs
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func TestSymlinkRelative(t *testing.T) {
+	fs := FileSystem(
+		Dir("foo",
+			File("real.txt", "hello"),
+			Symlink("link.txt", "real.txt"),
+		),
+	)
+
+	file, err := fs.Open("foo/link.txt")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b := new(bytes.Buffer)
+	b.ReadFrom(file)
+	file.Close()
+	if s := b.String(); s != "hello" {
+		t.Errorf("expected %q, got %q", "hello", s)
+	}
+}
+
+func TestSymlinkDotDot(t *testing.T) {
+	fs := FileSystem(
+		File("real.txt", "hello"),
+		Dir("foo",
+			Symlink("link.txt", "../real.txt"),
+		),
+	)
+
+	file, err := fs.Open("foo/link.txt")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b := new(bytes.Buffer)
+	b.ReadFrom(file)
+	file.Close()
+	if s := b.String(); s != "hello" {
+		t.Errorf("expected %q, got %q", "hello", s)
+	}
+}
+
+func TestSymlinkAbsolute(t *testing.T) {
+	fs := FileSystem(
+		File("real.txt", "hello"),
+		Dir("foo",
+			Symlink("link.txt", "/real.txt"),
+		),
+	)
+
+	file, err := fs.Open("foo/link.txt")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b := new(bytes.Buffer)
+	b.ReadFrom(file)
+	file.Close()
+	if s := b.String(); s != "hello" {
+		t.Errorf("expected %q, got %q", "hello", s)
+	}
+}
+
+func TestSymlinkToDir(t *testing.T) {
+	fs := FileSystem(
+		Dir("real",
+			File("hello.txt", "hello"),
+		),
+		Symlink("link", "real"),
+	)
+
+	file, err := fs.Open("link/hello.txt")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b := new(bytes.Buffer)
+	b.ReadFrom(file)
+	file.Close()
+	if s := b.String(); s != "hello" {
+		t.Errorf("expected %q, got %q", "hello", s)
+	}
+}
+
+func TestSymlinkLoop(t *testing.T) {
+	fs := FileSystem(
+		Symlink("a", "b"),
+		Symlink("b", "a"),
+	)
+
+	if _, err := fs.Open("a"); err != ErrSymlinkLoop {
+		t.Errorf("expected %v, got %v", ErrSymlinkLoop, err)
+	}
+}
+
+func TestSymlinkMaxDepth(t *testing.T) {
+	fs := FileSystem(
+		WithMaxSymlinkDepth(2),
+		File("real.txt", "hello"),
+		Symlink("a", "real.txt"),
+		Symlink("b", "a"),
+		Symlink("c", "b"),
+	)
+
+	if _, err := fs.Open("c"); err != ErrSymlinkLoop {
+		t.Errorf("expected %v, got %v", ErrSymlinkLoop, err)
+	}
+	if _, err := fs.Open("b"); err != nil {
+		t.Errorf("expected b to resolve within the depth limit, got %v", err)
+	}
+}
+
+func TestSymlinkIndexHTML(t *testing.T) {
+	fs := FileSystem(
+		Dir("docs",
+			Symlink("index.html", "other.html"),
+			File("other.html", "hi"),
+		),
+	)
+
+	file, err := fs.Open("docs")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	b := new(bytes.Buffer)
+	b.ReadFrom(file)
+	file.Close()
+	if s := b.String(); s != "hi" {
+		t.Errorf("expected docs to serve the resolved index.html, got %q", s)
+	}
+}
+
+func TestSymlinkInWritable(t *testing.T) {
+	w := Writable(
+		File("real.txt", "hello"),
+		Dir("foo",
+			Symlink("abs.txt", "/real.txt"),
+			Symlink("rel.txt", "../real.txt"),
+		),
+	)
+
+	for _, name := range []string{"foo/abs.txt", "foo/rel.txt"} {
+		file, err := w.Open(name)
+		if err != nil {
+			t.Fatalf("unexpected error opening %s: %v", name, err)
+		}
+		b := new(bytes.Buffer)
+		b.ReadFrom(file)
+		file.Close()
+		if s := b.String(); s != "hello" {
+			t.Errorf("expected %s to resolve to %q, got %q", name, "hello", s)
+		}
+	}
+}
+
+func TestWritableRenameSymlink(t *testing.T) {
+	w := Writable(
+		File("real.txt", "hello"),
+		Dir("foo", Symlink("link.txt", "../real.txt")),
+	)
+
+	if err := w.Rename("foo/link.txt", "foo/link2.txt"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := w.Open("foo/link.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+	file, err := w.Open("foo/link2.txt")
+	if err != nil {
+		t.Fatalf("unexpected error opening renamed symlink: %v", err)
+	}
+	b := new(bytes.Buffer)
+	b.ReadFrom(file)
+	file.Close()
+	if s := b.String(); s != "hello" {
+		t.Errorf("expected renamed symlink to resolve to %q, got %q", "hello", s)
+	}
+	if target, err := Readlink(w, "foo/link2.txt"); err != nil || target != "../real.txt" {
+		t.Errorf("expected renamed symlink to keep its target, got %q, %v", target, err)
+	}
+}
+
+func TestReadlink(t *testing.T) {
+	fs := FileSystem(
+		Dir("foo",
+			Symlink("link.txt", "real.txt"),
+		),
+	)
+
+	target, err := Readlink(fs, "foo/link.txt")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if target != "real.txt" {
+		t.Errorf("expected %q, got %q", "real.txt", target)
+	}
+
+	if _, err := Readlink(fs, "foo"); err == nil {
+		t.Errorf("expected error reading a non-symlink")
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2014 Paul Hammond.
+// This software is licensed under the MIT license, see LICENSE.txt for details.
+
+package fakehttpfs
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Writable returns a mutable, in-memory filesystem seeded with the given
+// files, inspired by spf13/afero's MemMapFs. The result still satisfies
+// http.FileSystem, and additionally supports Create, MkdirAll, Remove,
+// Rename, WriteFile and Chtimes, so a handler under test can read and
+// write the same tree during a single request cycle.
+//
+// All methods are guarded by a mutex, so reads and writes may safely
+// happen concurrently.
+func Writable(files ...http.File) *WritableFileSystem {
+	root := &dir{name: "", files: files}
+	root.linkParents()
+	return &WritableFileSystem{root: root}
+}
+
+// WritableFileSystem is the type returned by Writable.
+type WritableFileSystem struct {
+	mu   sync.Mutex
+	root *dir
+}
+
+func (w *WritableFileSystem) Open(name string) (http.File, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.root.Open(name)
+}
+
+// Create creates the named file, truncating it if it already exists. The
+// parent directory must already exist. The returned http.File also
+// implements io.Writer, so it can be written to like an *os.File.
+func (w *WritableFileSystem) Create(name string) (http.File, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	parent, base, err := w.parent(name)
+	if err != nil {
+		return nil, &os.PathError{Op: "create", Path: name, Err: err}
+	}
+	f := &file{name: base, mode: 0644}
+	parent.replaceOrAppend(base, f)
+	return f, nil
+}
+
+// MkdirAll creates the named directory, along with any missing parents,
+// similarly to os.MkdirAll. It is a no-op if name is already a directory.
+func (w *WritableFileSystem) MkdirAll(name string, perm os.FileMode) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	d := w.root
+	for _, part := range cleanParts(name) {
+		child, err := d.find(part)
+		if err != nil {
+			newDir := &dir{name: part, mode: perm | os.ModeDir, parent: d}
+			d.replaceOrAppend(part, newDir)
+			child = newDir
+		}
+		sub, ok := child.(*dir)
+		if !ok {
+			return &os.PathError{Op: "mkdir", Path: name, Err: errors.New("not a directory")}
+		}
+		d = sub
+	}
+	return nil
+}
+
+// Remove removes the named file or directory.
+func (w *WritableFileSystem) Remove(name string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	parent, base, err := w.parent(name)
+	if err != nil {
+		return &os.PathError{Op: "remove", Path: name, Err: err}
+	}
+	if !parent.remove(base) {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	return nil
+}
+
+// Rename renames (moves) oldname to newname. The parent directories of
+// both must already exist.
+func (w *WritableFileSystem) Rename(oldname, newname string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	oldParent, oldBase, err := w.parent(oldname)
+	if err != nil {
+		return &os.PathError{Op: "rename", Path: oldname, Err: err}
+	}
+	f, err := oldParent.find(oldBase)
+	if err != nil {
+		return &os.PathError{Op: "rename", Path: oldname, Err: err}
+	}
+	newParent, newBase, err := w.parent(newname)
+	if err != nil {
+		return &os.PathError{Op: "rename", Path: newname, Err: err}
+	}
+	switch t := f.(type) {
+	case *file:
+		t.mu.Lock()
+		t.name = newBase
+		t.mu.Unlock()
+	case *dir:
+		t.mu.Lock()
+		t.name = newBase
+		t.parent = newParent
+		t.mu.Unlock()
+	case *symlink:
+		t.mu.Lock()
+		t.name = newBase
+		t.mu.Unlock()
+	}
+	oldParent.remove(oldBase)
+	newParent.replaceOrAppend(newBase, f)
+	return nil
+}
+
+// WriteFile writes data to the named file, creating it (and truncating
+// it) if necessary, similarly to os.WriteFile. The parent directory must
+// already exist.
+func (w *WritableFileSystem) WriteFile(name string, data []byte, perm os.FileMode) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	parent, base, err := w.parent(name)
+	if err != nil {
+		return &os.PathError{Op: "writefile", Path: name, Err: err}
+	}
+	content := make([]byte, len(data))
+	copy(content, data)
+	parent.replaceOrAppend(base, &file{name: base, mode: perm, content: content})
+	return nil
+}
+
+// Chtimes changes the modification time of the named file or directory,
+// similarly to os.Chtimes. atime is accepted for interface compatibility
+// but is not stored, as this package doesn't track access times. Symlinks
+// have no settable mtime of their own (Stat on one always reports the
+// zero Time) and are silently ignored here, matching ModTime.
+func (w *WritableFileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	parent, base, err := w.parent(name)
+	if err != nil {
+		return &os.PathError{Op: "chtimes", Path: name, Err: err}
+	}
+	f, err := parent.find(base)
+	if err != nil {
+		return &os.PathError{Op: "chtimes", Path: name, Err: err}
+	}
+	switch t := f.(type) {
+	case *file:
+		t.mu.Lock()
+		t.modTime = mtime
+		t.mu.Unlock()
+	case *dir:
+		t.mu.Lock()
+		t.modTime = mtime
+		t.mu.Unlock()
+	}
+	return nil
+}
+
+// parent returns the directory that should contain name, and name's final
+// path element. It does not create any missing directories. w.mu must be
+// held by the caller.
+func (w *WritableFileSystem) parent(name string) (*dir, string, error) {
+	parts := cleanParts(name)
+	if len(parts) == 0 {
+		return nil, "", os.ErrInvalid
+	}
+	d := w.root
+	for _, part := range parts[:len(parts)-1] {
+		child, err := d.find(part)
+		if err != nil {
+			return nil, "", os.ErrNotExist
+		}
+		sub, ok := child.(*dir)
+		if !ok {
+			return nil, "", errors.New("not a directory")
+		}
+		d = sub
+	}
+	return d, parts[len(parts)-1], nil
+}
+
+// cleanParts splits name into non-empty path elements.
+func cleanParts(name string) []string {
+	name = strings.Trim(name, "/")
+	if name == "" || name == "." {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+// replaceOrAppend inserts f as name in d, replacing any existing entry
+// with that name. It acquires d.mu itself, the same lock Readdir, find
+// and Close use, so a handle opened on d stays safe to read from while
+// a WritableFileSystem mutator touches d concurrently.
+func (d *dir) replaceOrAppend(name string, f http.File) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, existing := range d.files {
+		if stat, err := existing.Stat(); err == nil && stat.Name() == name {
+			d.files[i] = f
+			return
+		}
+	}
+	d.files = append(d.files, f)
+}
+
+// remove removes the entry called name from d, reporting whether it was
+// found. It acquires d.mu itself; see replaceOrAppend.
+func (d *dir) remove(name string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, existing := range d.files {
+		if stat, err := existing.Stat(); err == nil && stat.Name() == name {
+			d.files = append(d.files[:i], d.files[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
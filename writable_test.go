@@ -0,0 +1,242 @@
+// Copyright 2014 Paul Hammond.
+// This software is licensed under the MIT license, see LICENSE.txt for details.
+
+package fakehttpfs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWritableCreateAndRead(t *testing.T) {
+	fs := Writable(Dir("foo"))
+
+	f, err := fs.Create("foo/bar.txt")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	w, ok := f.(io.Writer)
+	if !ok {
+		t.Fatalf("expected %T to implement io.Writer", f)
+	}
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	f.Close()
+
+	opened, err := fs.Open("foo/bar.txt")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer opened.Close()
+	b, err := ioutil.ReadAll(opened)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(b) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", string(b))
+	}
+}
+
+func TestWritableMkdirAll(t *testing.T) {
+	fs := Writable()
+
+	if err := fs.MkdirAll("a/b/c", 0755); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	f, err := fs.Open("a/b/c")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !stat.IsDir() {
+		t.Errorf("expected a/b/c to be a directory")
+	}
+}
+
+func TestWritableWriteFile(t *testing.T) {
+	fs := Writable(Dir("foo"))
+
+	if err := fs.WriteFile("foo/bar.txt", []byte("hello"), 0644); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	f, err := fs.Open("foo/bar.txt")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(b) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", string(b))
+	}
+
+	if err := fs.WriteFile("foo/bar.txt", []byte("bye"), 0644); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	f2, err := fs.Open("foo/bar.txt")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer f2.Close()
+	b2, _ := ioutil.ReadAll(f2)
+	if string(b2) != "bye" {
+		t.Errorf("expected %q, got %q", "bye", string(b2))
+	}
+}
+
+func TestWritableRemove(t *testing.T) {
+	fs := Writable(Dir("foo", File("bar.txt", "hello")))
+
+	if err := fs.Remove("foo/bar.txt"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := fs.Open("foo/bar.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+	if err := fs.Remove("foo/missing.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+}
+
+func TestWritableRename(t *testing.T) {
+	fs := Writable(Dir("foo", File("bar.txt", "hello")))
+
+	if err := fs.Rename("foo/bar.txt", "foo/baz.txt"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, err := fs.Open("foo/bar.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected not exist error, got %v", err)
+	}
+	f, err := fs.Open("foo/baz.txt")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer f.Close()
+	b, _ := ioutil.ReadAll(f)
+	if string(b) != "hello" {
+		t.Errorf("expected %q, got %q", "hello", string(b))
+	}
+}
+
+func TestWritableChtimes(t *testing.T) {
+	fs := Writable(Dir("foo", File("bar.txt", "hello")))
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if err := fs.Chtimes("foo/bar.txt", mtime, mtime); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	f, err := fs.Open("foo/bar.txt")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !stat.ModTime().Equal(mtime) {
+		t.Errorf("expected modtime %v, got %v", mtime, stat.ModTime())
+	}
+
+	if err := fs.Chtimes("foo", mtime, mtime); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	dirFile, err := fs.Open("foo")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	dirStat, err := dirFile.Stat()
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !dirStat.ModTime().Equal(mtime) {
+		t.Errorf("expected dir modtime %v, got %v", mtime, dirStat.ModTime())
+	}
+}
+
+// TestWritableConcurrentReadWrite exercises reading an already-open
+// directory handle while another goroutine mutates the same directory,
+// the scenario Writable's doc comment promises is safe.
+// Run with -race to catch a regression.
+func TestWritableConcurrentReadWrite(t *testing.T) {
+	fs := Writable(Dir("foo", File("a.txt", "hello")))
+
+	f, err := fs.Open("foo")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer f.Close()
+
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			if err := fs.WriteFile("foo/b.txt", []byte("hi"), 0644); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			if err := fs.Remove("foo/b.txt"); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			if _, err := f.Readdir(0); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		}
+	}()
+	wg.Wait()
+}
+
+// TestWritableConcurrentRenameAndStat holds a handle opened before a
+// Rename and reads its name via Stat while the rename is in flight, the
+// ordinary "handle held across a request while something else renames
+// the file" pattern. Run with -race to catch a regression.
+func TestWritableConcurrentRenameAndStat(t *testing.T) {
+	fs := Writable(File("a.txt", "hello"))
+
+	f, err := fs.Open("a.txt")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	defer f.Close()
+
+	const n = 2000
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			name := []string{"a.txt", "b.txt"}[i%2]
+			other := []string{"a.txt", "b.txt"}[(i+1)%2]
+			if err := fs.Rename(name, other); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < n; i++ {
+			stat, err := f.Stat()
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			_ = stat.Name()
+		}
+	}()
+	wg.Wait()
+}